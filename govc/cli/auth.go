@@ -0,0 +1,242 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var warnPasswordInURLOnce sync.Once
+
+// warnPasswordInURL flags the historical -u user:pass@host form, which
+// puts the password in argv and therefore in `ps` output and shell
+// history. It only ever prints once per process, since -u is resolved
+// repeatedly (once per govc invocation is enough to be seen, not once per
+// internal retry).
+func warnPasswordInURL() {
+	warnPasswordInURLOnce.Do(func() {
+		fmt.Fprintln(os.Stderr, "govc: warning: a password in -u/GOVMOMI_URL is visible in `ps` output and shell history; prefer GOVMOMI_USERNAME/GOVMOMI_PASSWORD, an OS keychain entry, or ~/.govmomi/credentials via -auth instead")
+	})
+}
+
+// AuthProvider resolves credentials for u. A provider that has no opinion
+// about u should return an empty user and a nil error so the chain can fall
+// through to the next provider; a non-nil error aborts the chain.
+type AuthProvider interface {
+	Credentials(ctx context.Context, u *url.URL) (user, pass string, err error)
+}
+
+// authProviders holds every provider registered with RegisterAuthProvider,
+// keyed by name. It is seeded with this package's built-in providers below.
+var authProviders = make(map[string]AuthProvider)
+
+// defaultAuthChain is the order Client.Process tries providers in when the
+// caller didn't force one with -auth. Binaries that register additional
+// providers (such as a vCenter SSO/OIDC token exchange) are responsible for
+// their own ordering; they are only reachable via -auth unless this slice
+// is changed to include them.
+var defaultAuthChain = []string{"env", "keychain", "file", "prompt"}
+
+// RegisterAuthProvider makes p available under name, for use as the -auth
+// flag value. It is typically called from an init function of a package
+// that implements an additional credential source, such as a SAML or OIDC
+// token exchange against vCenter's STS.
+func RegisterAuthProvider(name string, p AuthProvider) {
+	authProviders[name] = p
+}
+
+func init() {
+	RegisterAuthProvider("env", envAuthProvider{})
+	RegisterAuthProvider("keychain", keychainAuthProvider{})
+	RegisterAuthProvider("file", fileAuthProvider{})
+	RegisterAuthProvider("prompt", promptAuthProvider{})
+}
+
+// credentials resolves a username and password for c.u. If c.u already
+// carries userinfo (the historical -u user:pass@host form), that always
+// wins and no provider is consulted. Otherwise, if -auth named a specific
+// provider, only that provider is tried; if not, providers in
+// defaultAuthChain are tried in order and the first to return a non-empty
+// user wins.
+func (c *Client) credentials() (string, string, error) {
+	if c.u.User != nil {
+		pass, hasPass := c.u.User.Password()
+		if c.u.User.Username() != "" {
+			if hasPass {
+				warnPasswordInURL()
+			}
+			return c.u.User.Username(), pass, nil
+		}
+	}
+
+	ctx := context.Background()
+
+	if c.auth != "" {
+		p, ok := authProviders[c.auth]
+		if !ok {
+			return "", "", fmt.Errorf("unknown -auth provider %q", c.auth)
+		}
+		return p.Credentials(ctx, c.u)
+	}
+
+	for _, name := range defaultAuthChain {
+		p, ok := authProviders[name]
+		if !ok {
+			continue
+		}
+
+		user, pass, err := p.Credentials(ctx, c.u)
+		if err != nil {
+			return "", "", err
+		}
+		if user == "" {
+			continue
+		}
+
+		return user, pass, nil
+	}
+
+	return "", "", fmt.Errorf("no credentials available for %s", c.u.Host)
+}
+
+// envAuthProvider reads GOVMOMI_USERNAME and GOVMOMI_PASSWORD.
+type envAuthProvider struct{}
+
+func (envAuthProvider) Credentials(ctx context.Context, u *url.URL) (string, string, error) {
+	return os.Getenv("GOVMOMI_USERNAME"), os.Getenv("GOVMOMI_PASSWORD"), nil
+}
+
+// keychainAuthProvider looks up a host-keyed entry in the platform's native
+// credential store. The actual lookup is implemented per-OS: macOS
+// Keychain, Windows Credential Manager, or libsecret on Linux, behind the
+// keychainLookup build-tagged backend.
+type keychainAuthProvider struct{}
+
+const keychainService = "govmomi"
+
+func (keychainAuthProvider) Credentials(ctx context.Context, u *url.URL) (string, string, error) {
+	user, pass, err := keychainLookup(keychainService, u.Host)
+	if err != nil {
+		if errors.Is(err, errKeychainNotFound) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	return user, pass, nil
+}
+
+// fileAuthProvider reads ~/.govmomi/credentials, a per-host stanza file:
+//
+//	[vc1.example.com]
+//	username = administrator@vsphere.local
+//	password = hunter2
+//
+// A host is matched against the URL's Host (including port, if any).
+type fileAuthProvider struct{}
+
+func (fileAuthProvider) Credentials(ctx context.Context, u *url.URL) (string, string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", nil
+	}
+
+	f, err := os.Open(filepath.Join(home, ".govmomi", "credentials"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	defer f.Close()
+
+	var section, user, pass string
+	found := false
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		if section != u.Host {
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+
+		switch k {
+		case "username":
+			user, found = v, true
+		case "password":
+			pass, found = v, true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", "", err
+	}
+	if !found {
+		return "", "", nil
+	}
+
+	return user, pass, nil
+}
+
+// promptAuthProvider asks on the controlling terminal, and only if stdin is
+// actually one; it never blocks a script or CI job waiting on input that
+// will never arrive.
+type promptAuthProvider struct{}
+
+func (promptAuthProvider) Credentials(ctx context.Context, u *url.URL) (string, string, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", "", nil
+	}
+
+	fmt.Printf("Username for %s: ", u.Host)
+	var user string
+	if _, err := fmt.Scanln(&user); err != nil {
+		return "", "", err
+	}
+
+	fmt.Print("Password: ")
+	pass, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", "", err
+	}
+
+	return user, string(pass), nil
+}
@@ -0,0 +1,74 @@
+//go:build darwin || windows || linux
+// +build darwin windows linux
+
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+// errKeychainNotFound is returned by keychainLookup when service/host has
+// no entry in the native credential store, as opposed to a lookup failure.
+var errKeychainNotFound = errors.New("cli: no keychain entry")
+
+// keychainLookup reads a "user:pass" secret stored under service/host from
+// the host OS's native credential store: macOS Keychain, Windows Credential
+// Manager, or libsecret on Linux. Entries are written with the same
+// encoding by `govc session.keychain set`.
+func keychainLookup(service, host string) (user, pass string, err error) {
+	secret, err := keyring.Get(service, host)
+	if err != nil {
+		if errors.Is(err, keyring.ErrNotFound) {
+			return "", "", errKeychainNotFound
+		}
+		return "", "", err
+	}
+
+	idx := -1
+	for i := 0; i < len(secret); i++ {
+		if secret[i] == ':' {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return "", "", errors.New("cli: malformed keychain secret for " + host)
+	}
+
+	return secret[:idx], secret[idx+1:], nil
+}
+
+// keychainStore writes a "user:pass" secret under service/host to the host
+// OS's native credential store, in the encoding keychainLookup expects.
+// It backs `govc session.keychain set`.
+func keychainStore(service, host, user, pass string) error {
+	return keyring.Set(service, host, user+":"+pass)
+}
+
+// keychainDelete removes the entry stored under service/host, if any. It
+// backs `govc session.keychain remove`.
+func keychainDelete(service, host string) error {
+	err := keyring.Delete(service, host)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return errKeychainNotFound
+	}
+	return err
+}
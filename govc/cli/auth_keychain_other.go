@@ -0,0 +1,39 @@
+//go:build !darwin && !windows && !linux
+// +build !darwin,!windows,!linux
+
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import "errors"
+
+var errKeychainNotFound = errors.New("cli: no keychain entry")
+
+// keychainLookup has no backend on this platform.
+func keychainLookup(service, host string) (user, pass string, err error) {
+	return "", "", errKeychainNotFound
+}
+
+// keychainStore has no backend on this platform.
+func keychainStore(service, host, user, pass string) error {
+	return errors.New("cli: no keychain support on this platform")
+}
+
+// keychainDelete has no backend on this platform.
+func keychainDelete(service, host string) error {
+	return errors.New("cli: no keychain support on this platform")
+}
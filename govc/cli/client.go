@@ -17,10 +17,13 @@ limitations under the License.
 package cli
 
 import (
+	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/vmware/govmomi"
 )
@@ -29,7 +32,13 @@ const cDescr = "ESX or vCenter URL"
 
 type Client struct {
 	*govmomi.Client
-	u *url.URL
+	u         *url.URL
+	uExplicit bool
+	insecure  bool
+	auth      string
+	profile   string
+
+	interceptors []Interceptor
 }
 
 func (c *Client) String() string {
@@ -41,33 +50,130 @@ func (c *Client) String() string {
 	return ""
 }
 
-func (c *Client) Set(s string) error {
-	var err error
-
-	c.u, err = url.Parse(s)
+// seed parses s into c.u without marking it as explicitly given by the
+// user, for bootstrapping c.u from GOVMOMI_URL before flag.Parse runs.
+func (c *Client) seed(s string) error {
+	u, err := url.Parse(s)
 	if err != nil {
 		return err
 	}
 
+	c.u = u
+
+	return nil
+}
+
+// Set implements flag.Value. It is only called by the flag package when
+// -u is actually passed on the command line, so unlike seed it marks c.u
+// as explicitly given; this is what lets -u (and only -u) override a
+// profile's URL in applyProfile.
+func (c *Client) Set(s string) error {
+	if err := c.seed(s); err != nil {
+		return err
+	}
+
+	c.uExplicit = true
+
 	return nil
 }
 
 func (c *Client) Register(f *flag.FlagSet) {
-	c.Set(os.Getenv("GOVMOMI_URL"))
+	c.seed(os.Getenv("GOVMOMI_URL"))
 	f.Var(c, "u", cDescr)
+	f.StringVar(&c.auth, "auth", "", "Name of the auth provider to use, instead of the default credential chain")
+	f.BoolVar(&c.insecure, "k", false, "Skip verification of server certificate")
+
+	profile := os.Getenv("GOVMOMI_PROFILE")
+	if profile == "" {
+		if cfg, err := loadConfig(); err == nil {
+			profile = cfg.Default
+		}
+	}
+	f.StringVar(&c.profile, "profile", profile, "Name of a profile in "+configDescr())
+}
+
+func configDescr() string {
+	path, err := configPath()
+	if err != nil {
+		return "$GOVMOMI_CONFIG"
+	}
+	return path
+}
+
+// applyProfile fills in c.u, and c.auth if unset, from the named profile.
+// c.u is left alone only if -u was given on the command line; a URL seeded
+// from GOVMOMI_URL is replaced by the profile's. -u always wins.
+func (c *Client) applyProfile() error {
+	if c.profile == "" {
+		return nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	p, ok := cfg.Profiles[c.profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s", c.profile, configDescr())
+	}
+
+	if !c.uExplicit {
+		if err := c.seed(p.URL); err != nil {
+			return fmt.Errorf("profile %q: %s", c.profile, err)
+		}
+	}
+
+	if c.auth == "" {
+		c.auth = p.Auth
+	}
+
+	if p.Insecure {
+		c.insecure = true
+	}
+
+	return nil
 }
 
 func (c *Client) Process() error {
 	var err error
 
-	if c.u == nil {
+	if err := c.applyProfile(); err != nil {
+		return err
+	}
+
+	if c.u == nil || c.u.Host == "" {
 		return errors.New("specify an " + cDescr)
 	}
 
-	c.Client, err = govmomi.NewClient(*c.u)
+	user, pass, err := c.credentials()
 	if err != nil {
 		return err
 	}
+	if user != "" {
+		c.u.User = url.UserPassword(user, pass)
+	}
+
+	ctx := context.Background()
+
+	if cached, cerr := loadSession(c.u, c.insecure); cerr == nil {
+		c.Client = cached
+	} else {
+		c.Client, err = newClient(ctx, c.u, c.insecure)
+		if err != nil {
+			return err
+		}
+
+		// A failure to cache the session is not fatal; Process just
+		// falls back to a fresh login again next time.
+		_ = saveSession(c.u, c.Client)
+	}
+
+	c.Use(TraceInterceptor(c.u))
+	c.Use(RetryInterceptor(3, 500*time.Millisecond))
+	if user != "" {
+		c.Use(ReloginInterceptor(c, user, pass))
+	}
 
 	return nil
 }
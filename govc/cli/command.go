@@ -0,0 +1,95 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// Command is implemented by govc subcommands, such as "session.profile".
+// It mirrors Client's own Register/Process split: Register adds any flags
+// the command needs to f, and Run does the work once f has been parsed.
+type Command interface {
+	Register(f *flag.FlagSet)
+	Run(f *flag.FlagSet) error
+}
+
+// commands holds every subcommand registered with RegisterCommand, keyed
+// by its dotted govc name (e.g. "session.profile").
+var commands = make(map[string]Command)
+
+// RegisterCommand makes c available under name. It is called from the
+// init function of the file implementing the command; govc's top-level
+// command dispatcher looks commands up here by name.
+func RegisterCommand(name string, c Command) {
+	commands[name] = c
+}
+
+// LookupCommand returns the command registered under name, if any.
+func LookupCommand(name string) (Command, bool) {
+	c, ok := commands[name]
+	return c, ok
+}
+
+// sharedFlag is implemented by any flag struct, such as *Client, that a
+// command may embed and that needs its own flags registered exactly once,
+// no matter how many fields (direct or nested) end up referencing the same
+// instance. This is the interface govc's flag hierarchy used to dedup
+// against before this package's Walk was rewritten; see WalkSharedFlags.
+type sharedFlag interface {
+	Register(f *flag.FlagSet)
+}
+
+var sharedFlagType = reflect.TypeOf((*sharedFlag)(nil)).Elem()
+
+// Run looks up the command registered under name, registers its flags
+// together with those of any sharedFlag field it embeds (deduped via
+// WalkSharedFlags so a *Client reachable through more than one field is
+// only registered once), parses args against them and runs the command.
+// This is govc's top-level command dispatcher.
+func Run(name string, args []string) error {
+	cmd, ok := LookupCommand(name)
+	if !ok {
+		return fmt.Errorf("govc: unknown command %q", name)
+	}
+
+	f := flag.NewFlagSet(name, flag.ExitOnError)
+
+	err := WalkSharedFlags(cmd, sharedFlagType, func(v interface{}) error {
+		if v == cmd {
+			// cmd itself is registered below; WalkSharedFlags always
+			// calls fn once for the root value in addition to every
+			// shared field it finds.
+			return nil
+		}
+		v.(sharedFlag).Register(f)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cmd.Register(f)
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	return cmd.Run(f)
+}
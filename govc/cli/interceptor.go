@@ -0,0 +1,170 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// InvokeFunc performs one SOAP round-trip against c's vCenter/ESX.
+type InvokeFunc func(ctx context.Context) error
+
+// Interceptor wraps an InvokeFunc with cross-cutting behavior, such as
+// re-login, tracing or retries, much like a GraphQL or gRPC client chains
+// interceptors around a single call.
+type Interceptor interface {
+	Intercept(next InvokeFunc) InvokeFunc
+}
+
+// InterceptorFunc lets a plain function satisfy Interceptor.
+type InterceptorFunc func(next InvokeFunc) InvokeFunc
+
+func (f InterceptorFunc) Intercept(next InvokeFunc) InvokeFunc {
+	return f(next)
+}
+
+// Use appends i to c's interceptor chain. Interceptors added later run
+// closer to the actual SOAP call; i.e. the chain wraps outside-in in the
+// order Use was called.
+func (c *Client) Use(i Interceptor) {
+	c.interceptors = append(c.interceptors, i)
+}
+
+// Invoke runs op through every interceptor registered with Use, innermost
+// (most recently added) last. govc subcommands that want re-login, tracing
+// or retry behavior for a SOAP call should route it through Invoke instead
+// of calling the vim25 API directly.
+func (c *Client) Invoke(ctx context.Context, op InvokeFunc) error {
+	fn := op
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		fn = c.interceptors[i].Intercept(fn)
+	}
+	return fn(ctx)
+}
+
+// isNotAuthenticated reports whether err is the NotAuthenticated SOAP fault
+// vCenter/ESX returns when a session cookie has expired or been revoked, as
+// opposed to any other failure a SOAP call might return.
+func isNotAuthenticated(err error) bool {
+	if !soap.IsSoapFault(err) {
+		return false
+	}
+
+	_, ok := soap.ToSoapFault(err).VimFault().(types.NotAuthenticated)
+	return ok
+}
+
+// ReloginInterceptor retries op once, after logging back in with user/pass,
+// if and only if op failed with the NotAuthenticated SOAP fault. Any other
+// error, including one that happens to coincide with an idle session, is
+// returned as-is rather than silently re-executing op, since op may not be
+// idempotent (e.g. Destroy, CreateVM).
+func ReloginInterceptor(c *Client, user, pass string) Interceptor {
+	return InterceptorFunc(func(next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context) error {
+			err := next(ctx)
+			if err == nil || !isNotAuthenticated(err) {
+				return err
+			}
+
+			if lerr := c.SessionManager.Login(ctx, url.UserPassword(user, pass)); lerr != nil {
+				return err
+			}
+
+			return next(ctx)
+		}
+	})
+}
+
+// TraceInterceptor logs the start and duration of every call it wraps to a
+// file under the session cache directory, only when GOVMOMI_DEBUG=1.
+func TraceInterceptor(u *url.URL) Interceptor {
+	return InterceptorFunc(func(next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context) error {
+			if os.Getenv("GOVMOMI_DEBUG") != "1" {
+				return next(ctx)
+			}
+
+			f, err := traceFile(u)
+			if err != nil {
+				return next(ctx)
+			}
+			defer f.Close()
+
+			start := time.Now()
+			err = next(ctx)
+			fmt.Fprintf(f, "%s %s err=%v\n", start.Format(time.RFC3339), time.Since(start), err)
+
+			return err
+		}
+	})
+}
+
+func traceFile(u *url.URL) (*os.File, error) {
+	path, err := sessionCachePath(u)
+	if err != nil {
+		return nil, err
+	}
+
+	path = path[:len(path)-len(filepath.Ext(path))] + ".trace"
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+}
+
+// RetryInterceptor calls op up to attempts times in all, with exponential
+// backoff starting at base, while it fails with a network-transport
+// error (connection reset, timeout, DNS failure, etc). It does not retry
+// SOAP faults, since those indicate the request was understood and
+// answered.
+func RetryInterceptor(attempts int, base time.Duration) Interceptor {
+	return InterceptorFunc(func(next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context) error {
+			var err error
+
+			for i := 0; i < attempts; i++ {
+				err = next(ctx)
+				if err == nil {
+					return nil
+				}
+
+				if _, ok := err.(net.Error); !ok {
+					return err
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(base * time.Duration(1<<uint(i))):
+				}
+			}
+
+			return err
+		}
+	})
+}
@@ -0,0 +1,98 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// netErr satisfies net.Error, so RetryInterceptor treats it as transient.
+type netErr struct{}
+
+func (netErr) Error() string   { return "transient network error" }
+func (netErr) Timeout() bool   { return true }
+func (netErr) Temporary() bool { return true }
+
+func TestRetryInterceptorRetriesOnNetError(t *testing.T) {
+	var calls int
+	op := func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return netErr{}
+		}
+		return nil
+	}
+
+	fn := RetryInterceptor(5, time.Millisecond).Intercept(op)
+
+	if err := fn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls before success, got %d", calls)
+	}
+}
+
+func TestRetryInterceptorDoesNotRetryOtherErrors(t *testing.T) {
+	wantErr := errors.New("not a network error")
+
+	var calls int
+	op := func(ctx context.Context) error {
+		calls++
+		return wantErr
+	}
+
+	fn := RetryInterceptor(5, time.Millisecond).Intercept(op)
+
+	err := fn(context.Background())
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected the original error back, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected exactly 1 call for a non-network error, got %d", calls)
+	}
+}
+
+func TestIsNotAuthenticatedFalseForOrdinaryError(t *testing.T) {
+	if isNotAuthenticated(errors.New("boom")) {
+		t.Fatal("expected an ordinary error not to be treated as NotAuthenticated")
+	}
+}
+
+func TestReloginInterceptorIgnoresOrdinaryErrors(t *testing.T) {
+	wantErr := errors.New("boom")
+
+	var calls int
+	op := func(ctx context.Context) error {
+		calls++
+		return wantErr
+	}
+
+	c := &Client{}
+	fn := ReloginInterceptor(c, "user", "pass").Intercept(op)
+
+	err := fn(context.Background())
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected the original error back untouched, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected no retry for a non-NotAuthenticated error, got %d calls", calls)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Profile is one named connection in ~/.govmomi/config.yaml.
+type Profile struct {
+	URL      string `yaml:"url"`
+	Insecure bool   `yaml:"insecure,omitempty"`
+	Auth     string `yaml:"auth,omitempty"`
+}
+
+// config is the parsed form of ~/.govmomi/config.yaml (or the file
+// referenced by GOVMOMI_CONFIG):
+//
+//	default: prod
+//	profiles:
+//	  prod:
+//	    url: https://vc1/sdk
+//	    insecure: false
+//	    auth: keychain
+//	  lab:
+//	    url: https://vc2/sdk
+type config struct {
+	Default  string             `yaml:"default,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+func configPath() (string, error) {
+	if p := os.Getenv("GOVMOMI_CONFIG"); p != "" {
+		return p, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".govmomi", "config.yaml"), nil
+}
+
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &config{Profiles: map[string]Profile{}}, nil
+		}
+		return nil, err
+	}
+
+	c := &config{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]Profile{}
+	}
+
+	return c, nil
+}
+
+func saveConfig(c *config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// AddProfile adds or replaces the profile named name in the config file,
+// creating the file if it doesn't exist yet. It backs the `govc
+// session.profile add` subcommand.
+func AddProfile(name string, p Profile) error {
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c.Profiles[name] = p
+
+	return saveConfig(c)
+}
+
+// RemoveProfile removes the profile named name from the config file. It
+// backs the `govc session.profile remove` subcommand.
+func RemoveProfile(name string) error {
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("no such profile: %q", name)
+	}
+	delete(c.Profiles, name)
+
+	if c.Default == name {
+		c.Default = ""
+	}
+
+	return saveConfig(c)
+}
+
+// ListProfiles returns every profile in the config file. It backs the
+// `govc session.profile ls` subcommand.
+func ListProfiles() (map[string]Profile, error) {
+	c, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Profiles, nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func withConfig(t *testing.T) {
+	t.Helper()
+	t.Setenv("GOVMOMI_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+}
+
+func TestProfileAddRemoveListRoundTrip(t *testing.T) {
+	withConfig(t)
+
+	if err := AddProfile("prod", Profile{URL: "https://vc1/sdk", Insecure: true, Auth: "keychain"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddProfile("lab", Profile{URL: "https://vc2/sdk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("expected 2 profiles, got %d", len(profiles))
+	}
+	if got := profiles["prod"]; got.URL != "https://vc1/sdk" || !got.Insecure || got.Auth != "keychain" {
+		t.Fatalf("unexpected prod profile: %+v", got)
+	}
+
+	if err := RemoveProfile("lab"); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err = ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := profiles["lab"]; ok {
+		t.Fatal("expected lab profile to be removed")
+	}
+	if _, ok := profiles["prod"]; !ok {
+		t.Fatal("expected prod profile to survive removing lab")
+	}
+}
+
+func TestRemoveProfileUnknown(t *testing.T) {
+	withConfig(t)
+
+	if err := RemoveProfile("does-not-exist"); err == nil {
+		t.Fatal("expected an error removing a profile that was never added")
+	}
+}
+
+func TestApplyProfileFillsURLAndAuth(t *testing.T) {
+	withConfig(t)
+
+	if err := AddProfile("prod", Profile{URL: "https://vc1/sdk", Insecure: true, Auth: "keychain"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Client{profile: "prod"}
+	if err := c.applyProfile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.u == nil || c.u.Host != "vc1" {
+		t.Fatalf("expected profile URL to seed c.u, got %v", c.u)
+	}
+	if c.auth != "keychain" {
+		t.Fatalf("expected profile auth to fill c.auth, got %q", c.auth)
+	}
+	if !c.insecure {
+		t.Fatal("expected profile insecure to set c.insecure")
+	}
+}
+
+func TestApplyProfileExplicitURLWins(t *testing.T) {
+	withConfig(t)
+
+	if err := AddProfile("prod", Profile{URL: "https://vc1/sdk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Client{profile: "prod"}
+	if err := c.Set("https://explicit.example/sdk"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.applyProfile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.u.Host != "explicit.example" {
+		t.Fatalf("expected -u to win over the profile URL, got %q", c.u.Host)
+	}
+}
+
+func TestApplyProfileUnknownName(t *testing.T) {
+	withConfig(t)
+
+	c := &Client{profile: "does-not-exist"}
+	if err := c.applyProfile(); err == nil {
+		t.Fatal("expected an error for an unknown profile name")
+	}
+}
+
+func TestApplyProfileNoOpWithoutProfile(t *testing.T) {
+	withConfig(t)
+
+	c := &Client{}
+	if err := c.applyProfile(); err != nil {
+		t.Fatal(err)
+	}
+	if c.u != nil {
+		t.Fatalf("expected c.u to stay nil with no profile set, got %v", c.u)
+	}
+}
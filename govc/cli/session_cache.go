@@ -0,0 +1,166 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// sessionCache is the on-disk representation of a cached session, written
+// with mode 0600 to $XDG_CACHE_HOME/govmomi/<sha1(url)>.session (or
+// ~/.cache/govmomi/... if XDG_CACHE_HOME is unset).
+type sessionCache struct {
+	ServiceContent types.ServiceContent `json:"serviceContent"`
+	Cookies        []*http.Cookie       `json:"cookies"`
+}
+
+func sessionCachePath(u *url.URL) (string, error) {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+
+	// u may carry resolved userinfo by the time Process calls us; the
+	// cache key must depend only on the URL, not the credentials, or an
+	// ordinary password rotation orphans the old cache file instead of
+	// just refreshing it. Strip it first, same as Client.String().
+	withoutCredentials := *u
+	withoutCredentials.User = nil
+
+	sum := sha1.Sum([]byte(withoutCredentials.String()))
+	return filepath.Join(dir, "govmomi", fmt.Sprintf("%x.session", sum)), nil
+}
+
+// newClient builds a fresh govmomi.Client against u, logging in with u's
+// userinfo if present. insecure controls whether the server's TLS
+// certificate is verified, as set by -k or a profile's insecure option.
+func newClient(ctx context.Context, u *url.URL, insecure bool) (*govmomi.Client, error) {
+	soapClient := soap.NewClient(u, insecure)
+
+	vimClient, err := vim25.NewClient(ctx, soapClient)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
+
+	if u.User != nil {
+		if err := client.SessionManager.Login(ctx, u.User); err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// saveSession persists c's ServiceContent and session cookies for u, so a
+// later Process call can skip a fresh login.
+func saveSession(u *url.URL, c *govmomi.Client) error {
+	path, err := sessionCachePath(u)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	cache := sessionCache{
+		ServiceContent: c.ServiceContent,
+		Cookies:        c.Client.Jar.Cookies(u),
+	}
+
+	data, err := json.Marshal(&cache)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// loadSession rebuilds a govmomi.Client from a cached session for u,
+// without performing a fresh login, and confirms the session is still
+// usable with a cheap SessionManager.SessionIsActive probe. It returns
+// os.ErrNotExist (wrapped) if there is no cache for u, and any other error
+// if the cache exists but the session it describes is no longer valid.
+func loadSession(u *url.URL, insecure bool) (*govmomi.Client, error) {
+	path, err := sessionCachePath(u)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cache sessionCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	}
+
+	soapClient := soap.NewClient(u, insecure)
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	jar.SetCookies(u, cache.Cookies)
+	soapClient.Jar = jar
+
+	vimClient := &vim25.Client{
+		Client:         soapClient,
+		ServiceContent: cache.ServiceContent,
+		RoundTripper:   soapClient,
+	}
+
+	client := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
+
+	ctx := context.Background()
+	active, err := client.SessionManager.SessionIsActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, fmt.Errorf("cli: cached session for %s is no longer active", u.Host)
+	}
+
+	return client, nil
+}
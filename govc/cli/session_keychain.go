@@ -0,0 +1,81 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+func init() {
+	RegisterCommand("session.keychain", new(keychainCommand))
+}
+
+// keychainCommand implements `govc session.keychain set|remove`, the write
+// path for keychainAuthProvider: it populates the host-keyed entry that
+// provider reads from the OS's native credential store.
+type keychainCommand struct{}
+
+func (cmd *keychainCommand) Register(f *flag.FlagSet) {}
+
+func (cmd *keychainCommand) Run(f *flag.FlagSet) error {
+	args := f.Args()
+	if len(args) == 0 {
+		return fmt.Errorf("usage: session.keychain {set <host> <user> | remove <host>}")
+	}
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "set":
+		return cmd.set(args)
+	case "remove", "rm":
+		return cmd.remove(args)
+	default:
+		return fmt.Errorf("usage: session.keychain {set <host> <user> | remove <host>}")
+	}
+}
+
+func (cmd *keychainCommand) set(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: session.keychain set <host> <user>")
+	}
+	host, user := args[0], args[1]
+
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("session.keychain set requires a terminal to prompt for a password")
+	}
+
+	fmt.Print("Password: ")
+	pass, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+
+	return keychainStore(keychainService, host, user, string(pass))
+}
+
+func (cmd *keychainCommand) remove(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: session.keychain remove <host>")
+	}
+
+	return keychainDelete(keychainService, args[0])
+}
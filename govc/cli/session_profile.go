@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+func init() {
+	RegisterCommand("session.profile", new(profileCommand))
+}
+
+// profileCommand implements `govc session.profile add|remove|ls`, so
+// profiles in ~/.govmomi/config.yaml (or $GOVMOMI_CONFIG) can be managed
+// without hand-editing the file.
+type profileCommand struct {
+	insecure bool
+	auth     string
+}
+
+func (cmd *profileCommand) Register(f *flag.FlagSet) {
+	f.BoolVar(&cmd.insecure, "insecure", false, "Skip verification of server certificate for this profile")
+	f.StringVar(&cmd.auth, "auth", "", "Name of the auth provider this profile should use")
+}
+
+func (cmd *profileCommand) Run(f *flag.FlagSet) error {
+	args := f.Args()
+	if len(args) == 0 {
+		return cmd.list()
+	}
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "add":
+		return cmd.add(args)
+	case "remove", "rm":
+		return cmd.remove(args)
+	case "ls", "list":
+		return cmd.list()
+	default:
+		return fmt.Errorf("usage: session.profile {add <name> <url> | remove <name> | ls}")
+	}
+}
+
+func (cmd *profileCommand) add(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: session.profile [-insecure] [-auth provider] add <name> <url>")
+	}
+
+	return AddProfile(args[0], Profile{
+		URL:      args[1],
+		Insecure: cmd.insecure,
+		Auth:     cmd.auth,
+	})
+}
+
+func (cmd *profileCommand) remove(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: session.profile remove <name>")
+	}
+
+	return RemoveProfile(args[0])
+}
+
+func (cmd *profileCommand) list() error {
+	profiles, err := ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := profiles[name]
+		fmt.Printf("%s\t%s\tinsecure=%v\tauth=%s\n", name, p.URL, p.Insecure, p.Auth)
+	}
+
+	return nil
+}
@@ -17,99 +17,464 @@ limitations under the License.
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 )
 
-type WalkFn func(c interface{}) error
+// SkipEntry can be returned by a StructFieldWalker, MapElemWalker or
+// SliceElemWalker to tell Walk not to descend into the value it was just
+// handed, without aborting the rest of the walk. This mirrors the
+// os.SkipDir convention from filepath.Walk.
+var SkipEntry = errors.New("cli: skip this entry")
 
-// Walk recursively walks struct types that implement the specified interface.
-// Fields that implement the specified interface are expected to be pointer
-// values. This allows the function to cache pointer values on a per-type
-// basis. If, during a recursive walk, the same type is encountered twice, the
-// function creates a new value of that type the first time, and reuses that
-// same value the second time.
-//
-// This function is used to make sure that a hierarchy of flags where multiple
-// structs refer to the `Client` flag will not end up with more than one
-// instance of the actual client. Rather, every struct referring to the
-// `Client` flag will have a pointer to the same underlying `Client` struct.
+// Location identifies where in a value's structure a walk callback is being
+// invoked, and is passed to the Enter/Exit pair of an EnterExitWalker so a
+// visitor can track how deeply it is nested.
+type Location int
+
+const (
+	None Location = iota
+	Struct
+	StructField
+	Map
+	MapElem
+	Slice
+	SliceElem
+	Pointer
+	Interface
+	Primitive
+)
+
+// WalkFn is kept for visitors that only care about being called once per
+// struct value, such as SharedFlagWalker.
+type WalkFn func(v interface{}) error
+
+// The following interfaces are implemented selectively by a visitor passed to
+// Walk. Each is optional: a visitor only needs to implement the ones it
+// cares about, similar to mitchellh/reflectwalk. Walk uses a type assertion
+// on the visitor before invoking each one.
+type EnterExitWalker interface {
+	Enter(l Location) error
+	Exit(l Location) error
+}
+
+type StructWalker interface {
+	Struct(v reflect.Value) error
+}
+
+type StructFieldWalker interface {
+	StructField(f reflect.StructField, v reflect.Value) error
+}
+
+type MapWalker interface {
+	Map(v reflect.Value) error
+}
+
+type MapElemWalker interface {
+	MapElem(m, k, v reflect.Value) error
+}
+
+type SliceWalker interface {
+	Slice(v reflect.Value) error
+}
+
+type SliceElemWalker interface {
+	SliceElem(i int, v reflect.Value) error
+}
+
+type PointerWalker interface {
+	Pointer(v reflect.Value) error
+}
+
+type InterfaceWalker interface {
+	Interface(v reflect.Value) error
+}
+
+type PrimitiveWalker interface {
+	Primitive(v reflect.Value) error
+}
+
+// Walk recursively walks v, which may be a struct, map, slice, array,
+// pointer or interface value (possibly nested in any combination), invoking
+// the optional callbacks implemented by w as it goes. Unexported struct
+// fields are skipped since they cannot be read or set via reflection.
 //
-func Walk(c interface{}, ifaceType reflect.Type, fn WalkFn) error {
-	var walker WalkFn
-
-	visited := make(map[reflect.Type]reflect.Value)
-	walker = func(c interface{}) error {
-		v := reflect.ValueOf(c).Elem()
-		t := v.Type()
-
-		for i := 0; i < t.NumField(); i++ {
-			ff := t.Field(i)
-			ft := ff.Type
-			fv := v.Field(i)
-
-			// Check that a pointer to this field's type doesn't implement the
-			// specified interface. If it does, this field references the type as
-			// value. This is not allowed because it prohibits a value from being
-			// shared among multiple structs that reference it.
-			//
-			// For example: if a struct has two fields of the same type, they must
-			// both point to the same value after this routine has executed. If these
-			// fields are not a pointer type, the value cannot be shared.
-			//
-			if reflect.PtrTo(ft).Implements(ifaceType) {
-				panic(fmt.Sprintf(`field "%s" in struct "%s" must be a pointer`, ff.Name, v.Type()))
+// Walk guards against cycles introduced by self-referential pointers: once a
+// given pointer value has been walked, a later encounter with the same
+// pointer is reported to PointerWalker/EnterExitWalker as usual but is not
+// recursed into again, so a graph like `type Node struct { Next *Node }`
+// cannot make Walk recurse forever.
+func Walk(v interface{}, w interface{}) error {
+	return walk(reflect.ValueOf(v), &walkState{w: w, seen: make(map[uintptr]bool)})
+}
+
+// walkState threads the visitor and the set of already-visited pointers
+// through the recursive walk* functions, so cycle detection can span the
+// whole walk rather than just one call's immediate children.
+type walkState struct {
+	w    interface{}
+	seen map[uintptr]bool
+}
+
+func walk(v reflect.Value, s *walkState) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		return walkPointer(v, s)
+	case reflect.Interface:
+		return walkInterface(v, s)
+	case reflect.Struct:
+		return walkStruct(v, s)
+	case reflect.Map:
+		return walkMap(v, s)
+	case reflect.Slice, reflect.Array:
+		return walkSlice(v, s)
+	default:
+		// Every other reflect.Kind (bools, numbers, strings, Chan, Func,
+		// UnsafePointer, ...) is treated as a leaf and reported as
+		// Primitive, the same as every other kind-specific walk function,
+		// so Enter/Exit always nest correctly regardless of what PrimitiveWalker
+		// itself does with it.
+		if err := enter(s.w, Primitive); err != nil {
+			return err
+		}
+
+		if pw, ok := s.w.(PrimitiveWalker); ok {
+			if err := pw.Primitive(v); err != nil {
+				return err
 			}
+		}
+
+		return exit(s.w, Primitive)
+	}
+}
 
-			// Type must implement specified interface.
-			if !ft.Implements(ifaceType) {
-				continue
+func enter(w interface{}, l Location) error {
+	if ee, ok := w.(EnterExitWalker); ok {
+		return ee.Enter(l)
+	}
+	return nil
+}
+
+func exit(w interface{}, l Location) error {
+	if ee, ok := w.(EnterExitWalker); ok {
+		return ee.Exit(l)
+	}
+	return nil
+}
+
+func walkPointer(v reflect.Value, s *walkState) error {
+	if err := enter(s.w, Pointer); err != nil {
+		return err
+	}
+
+	if pw, ok := s.w.(PointerWalker); ok {
+		if err := pw.Pointer(v); err != nil {
+			return err
+		}
+	}
+
+	if !v.IsNil() {
+		addr := v.Pointer()
+		if !s.seen[addr] {
+			s.seen[addr] = true
+			if err := walk(v.Elem(), s); err != nil {
+				return err
 			}
+		}
+	}
+
+	return exit(s.w, Pointer)
+}
+
+func walkInterface(v reflect.Value, s *walkState) error {
+	if v.IsNil() {
+		return nil
+	}
+
+	if err := enter(s.w, Interface); err != nil {
+		return err
+	}
+
+	if iw, ok := s.w.(InterfaceWalker); ok {
+		if err := iw.Interface(v); err != nil {
+			return err
+		}
+	}
 
-			// Type must be a pointer.
-			if ft.Kind() != reflect.Ptr {
-				panic(fmt.Sprintf(`field "%s" in struct "%s" must be a pointer`, ff.Name, v.Type()))
+	if err := walk(v.Elem(), s); err != nil {
+		return err
+	}
+
+	return exit(s.w, Interface)
+}
+
+func walkStruct(v reflect.Value, s *walkState) error {
+	if err := enter(s.w, Struct); err != nil {
+		return err
+	}
+
+	if sw, ok := s.w.(StructWalker); ok {
+		if err := sw.Struct(v); err != nil {
+			return err
+		}
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		ff := t.Field(i)
+
+		// Unexported fields cannot be read or set via reflection; skip
+		// them rather than panicking, here and for every visitor.
+		if ff.PkgPath != "" {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		if err := enter(s.w, StructField); err != nil {
+			return err
+		}
+
+		skip := false
+		if sfw, ok := s.w.(StructFieldWalker); ok {
+			if err := sfw.StructField(ff, fv); err != nil {
+				if err == SkipEntry {
+					skip = true
+				} else {
+					return err
+				}
 			}
+		}
 
-			// Field must not be anonymous.
-			//
-			// Fields implementing the specified interface may not be anonymous,
-			// because it can make its parent struct implement the specified
-			// interface implicitly.
-			//
-			// This, in turn, means that the functions as specified per the interface
-			// may be called twice: once on the parent, where the calls as dispatched
-			// to this field's type, and once for the field itself.
-			//
-			if ff.Anonymous {
-				panic(fmt.Sprintf(`field "%s" in struct "%s" must not be anonymous`, ff.Name, v.Type()))
+		// Anonymous (embedded) fields are walked like any other field;
+		// callers that need the old "anonymous fields are forbidden"
+		// rule implement that themselves via StructField.
+		if !skip {
+			if err := walk(fv, s); err != nil {
+				return err
 			}
+		}
+
+		if err := exit(s.w, StructField); err != nil {
+			return err
+		}
+	}
+
+	return exit(s.w, Struct)
+}
+
+func walkMap(v reflect.Value, s *walkState) error {
+	if err := enter(s.w, Map); err != nil {
+		return err
+	}
 
-			if _, ok := visited[ft]; !ok {
-				if fv.IsNil() {
-					visited[ft] = reflect.New(ft.Elem())
+	if mw, ok := s.w.(MapWalker); ok {
+		if err := mw.Map(v); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range v.MapKeys() {
+		ev := v.MapIndex(k)
+
+		if err := enter(s.w, MapElem); err != nil {
+			return err
+		}
+
+		skip := false
+		if mew, ok := s.w.(MapElemWalker); ok {
+			if err := mew.MapElem(v, k, ev); err != nil {
+				if err == SkipEntry {
+					skip = true
 				} else {
-					visited[ft] = fv
+					return err
 				}
+			}
+		}
+
+		if !skip {
+			if err := walk(ev, s); err != nil {
+				return err
+			}
+		}
+
+		if err := exit(s.w, MapElem); err != nil {
+			return err
+		}
+	}
+
+	return exit(s.w, Map)
+}
+
+func walkSlice(v reflect.Value, s *walkState) error {
+	if err := enter(s.w, Slice); err != nil {
+		return err
+	}
+
+	if sw, ok := s.w.(SliceWalker); ok {
+		if err := sw.Slice(v); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		ev := v.Index(i)
+
+		if err := enter(s.w, SliceElem); err != nil {
+			return err
+		}
 
-				// Not seen before, recurse.
-				err := walker(visited[ft].Interface())
-				if err != nil {
+		skip := false
+		if sew, ok := s.w.(SliceElemWalker); ok {
+			if err := sew.SliceElem(i, ev); err != nil {
+				if err == SkipEntry {
+					skip = true
+				} else {
 					return err
 				}
 			}
+		}
 
-			fv.Set(visited[ft])
+		if !skip {
+			if err := walk(ev, s); err != nil {
+				return err
+			}
 		}
 
-		// Call user specified function.
-		err := fn(c)
-		if err != nil {
+		if err := exit(s.w, SliceElem); err != nil {
 			return err
 		}
+	}
 
-		return nil
+	return exit(s.w, Slice)
+}
+
+// SharedFlagWalker is a visitor, built on top of the generic Walk, that
+// reproduces the original behavior of this package's Walk function: it
+// recurses into struct fields whose type implements ifaceType, and makes
+// sure that every field referencing a given type ends up pointing at the
+// same underlying value. This is used to make sure that a hierarchy of
+// flags where multiple structs refer to the `Client` flag will not end up
+// with more than one instance of the actual client. Rather, every struct
+// referring to the `Client` flag will have a pointer to the same underlying
+// `Client` struct.
+//
+// By default, a field whose type implements ifaceType by value, or an
+// anonymous field whose type implements ifaceType, causes a panic, exactly
+// as the original Walk did. Setting Permissive turns both of those cases
+// into a silent skip instead, for callers that cannot guarantee the field
+// shapes `govc`'s flag hierarchy relies on.
+//
+// Fields that don't implement ifaceType are left alone entirely: this
+// walker's StructField returns SkipEntry for them, so Walk never descends
+// into whatever maps, slices or nested structs they happen to contain. A
+// cycle reachable only through a field implementing ifaceType is still
+// caught, since the dedup above means such a field is only ever walked
+// once per type; any other cycle is covered by Walk's own pointer-cycle
+// guard.
+type SharedFlagWalker struct {
+	ifaceType  reflect.Type
+	fn         WalkFn
+	permissive bool
+
+	visited map[reflect.Type]reflect.Value
+}
+
+// WalkSharedFlags walks c exactly as this package's original Walk function
+// did: fn is called once for c itself and once for every distinct pointer
+// value reachable through a field whose type implements ifaceType, with
+// those pointers shared across every struct that references them.
+func WalkSharedFlags(c interface{}, ifaceType reflect.Type, fn WalkFn) error {
+	if err := Walk(c, NewSharedFlagWalker(ifaceType, fn)); err != nil {
+		return err
+	}
+	return fn(c)
+}
+
+// NewSharedFlagWalker returns a SharedFlagWalker that dedups pointer values
+// of fields implementing ifaceType, calling fn once for the shared value of
+// each such field type, after walking into it (innermost first).
+func NewSharedFlagWalker(ifaceType reflect.Type, fn WalkFn) *SharedFlagWalker {
+	return &SharedFlagWalker{
+		ifaceType: ifaceType,
+		fn:        fn,
+		visited:   make(map[reflect.Type]reflect.Value),
+	}
+}
+
+// Permissive puts w in PermissiveMode: instead of panicking on a
+// non-pointer or anonymous field implementing ifaceType, w silently leaves
+// the field untouched.
+func (w *SharedFlagWalker) Permissive(permissive bool) *SharedFlagWalker {
+	w.permissive = permissive
+	return w
+}
+
+func (w *SharedFlagWalker) StructField(ff reflect.StructField, fv reflect.Value) error {
+	ft := ff.Type
+
+	// Check that a pointer to this field's type doesn't implement the
+	// specified interface. If it does, this field references the type as
+	// value. This is not allowed because it prohibits a value from being
+	// shared among multiple structs that reference it.
+	if reflect.PtrTo(ft).Implements(w.ifaceType) {
+		if w.permissive {
+			return SkipEntry
+		}
+		panic(fmt.Sprintf(`field "%s" must be a pointer`, ff.Name))
+	}
+
+	// Type must implement specified interface. Fields that don't are of
+	// no interest to this walker: the original Walk only ever recursed
+	// into fields implementing ifaceType, so skip this one rather than
+	// letting the generic Walk fall through into its maps, slices or
+	// nested structs.
+	if !ft.Implements(w.ifaceType) {
+		return SkipEntry
+	}
+
+	// Type must be a pointer.
+	if ft.Kind() != reflect.Ptr {
+		if w.permissive {
+			return SkipEntry
+		}
+		panic(fmt.Sprintf(`field "%s" must be a pointer`, ff.Name))
+	}
+
+	// Fields implementing the specified interface may not be anonymous,
+	// because it can make its parent struct implement the specified
+	// interface implicitly. This, in turn, means that the functions as
+	// specified per the interface may be called twice: once on the
+	// parent, where the calls as dispatched to this field's type, and
+	// once for the field itself.
+	if ff.Anonymous {
+		if w.permissive {
+			return SkipEntry
+		}
+		panic(fmt.Sprintf(`field "%s" must not be anonymous`, ff.Name))
 	}
 
-	return walker(c)
+	if _, ok := w.visited[ft]; !ok {
+		if fv.IsNil() {
+			w.visited[ft] = reflect.New(ft.Elem())
+		} else {
+			w.visited[ft] = fv
+		}
+
+		// Not seen before: recurse into it ourselves so fn is called
+		// exactly once per type, then keep Walk from also recursing
+		// into fv below via the returned SkipEntry.
+		if err := Walk(w.visited[ft].Interface(), w); err != nil {
+			return err
+		}
+
+		if err := w.fn(w.visited[ft].Interface()); err != nil {
+			return err
+		}
+	}
+
+	fv.Set(w.visited[ft])
+
+	return SkipEntry
 }
@@ -0,0 +1,139 @@
+/*
+Copyright (c) 2014 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+// spyWalker records every Enter/Exit pair it sees, so a test can assert
+// they nest correctly around every kind of value, including primitives.
+type spyWalker struct {
+	stack []Location
+	bad   bool
+}
+
+func (s *spyWalker) Enter(l Location) error {
+	s.stack = append(s.stack, l)
+	return nil
+}
+
+func (s *spyWalker) Exit(l Location) error {
+	if len(s.stack) == 0 || s.stack[len(s.stack)-1] != l {
+		s.bad = true
+		return nil
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+	return nil
+}
+
+func TestWalkPrimitiveEnterExit(t *testing.T) {
+	type T struct {
+		N int
+		S string
+	}
+
+	s := &spyWalker{}
+	if err := Walk(T{N: 1, S: "x"}, s); err != nil {
+		t.Fatal(err)
+	}
+	if s.bad {
+		t.Fatal("Enter/Exit did not nest correctly around a primitive field")
+	}
+	if len(s.stack) != 0 {
+		t.Fatalf("stack not empty after walk: %v", s.stack)
+	}
+}
+
+// pointerCounter calls inc every time Pointer is invoked; used to confirm
+// Walk reports a cyclic pointer on each encounter but recurses into it only
+// once instead of forever.
+type pointerCounter struct {
+	inc func()
+}
+
+func (p *pointerCounter) Pointer(v reflect.Value) error {
+	p.inc()
+	return nil
+}
+
+func TestWalkPointerCycle(t *testing.T) {
+	type Node struct {
+		Next *Node
+	}
+
+	a := &Node{}
+	a.Next = a // self-referential
+
+	var visits int
+	counter := &pointerCounter{inc: func() { visits++ }}
+
+	if err := Walk(a, counter); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once for a itself, once for a.Next (the same pointer, reported but not
+	// recursed into again).
+	if visits != 2 {
+		t.Fatalf("expected the cyclic pointer to be reported exactly twice, got %d", visits)
+	}
+}
+
+type markableIface interface {
+	Mark()
+}
+
+type markableT struct{}
+
+func (*markableT) Mark() {}
+
+func TestSharedFlagWalkerDedupsAndSkipsOtherFields(t *testing.T) {
+	type Inner struct {
+		Untouched []string // must not be recursed into by SharedFlagWalker
+	}
+
+	type Outer struct {
+		A     *markableT
+		B     *markableT
+		Other Inner
+	}
+
+	o := &Outer{A: &markableT{}, B: nil, Other: Inner{Untouched: []string{"x"}}}
+
+	ifaceType := reflect.TypeOf((*markableIface)(nil)).Elem()
+
+	var seen []interface{}
+	fn := func(v interface{}) error {
+		seen = append(seen, v)
+		return nil
+	}
+
+	if err := WalkSharedFlags(o, ifaceType, fn); err != nil {
+		t.Fatal(err)
+	}
+
+	if o.A != o.B {
+		t.Fatalf("expected A and B to share the same pointer, got %p and %p", o.A, o.B)
+	}
+
+	// fn is called once per distinct *markableT value, plus once for o
+	// itself: exactly 2 times here.
+	if len(seen) != 2 {
+		t.Fatalf("expected fn to be called 2 times, got %d", len(seen))
+	}
+}